@@ -3,13 +3,13 @@ package main
 import (
 	"archive/tar"
 	"archive/zip"
-	"crypto/sha256"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"sort"
+	"strings"
+	"sync"
 
 	"github.com/alecthomas/kingpin"
 	"github.com/calennert/archive"
@@ -32,55 +32,196 @@ const (
 	fmtExists   string = "      Exists : %s\n"
 	fmtEmpty    string = "      Empty  : %s\n"
 	fmtRemoved  string = "      Removed: %s%s\n"
+	fmtEscaped  string = "      Escaped: %s\n"
 
 	reasonVerificationFailure string = " (file failed verification)"
 	reasonFileNotFound        string = " (file not found in target directory)"
 	reasonDirNotFound         string = " (directory does not found)"
-
-	errTypeDetermination string = "Unable to determine the file's archive type. Specify with the -type argument."
-	errUnrecognizedType  string = "The type specified with the -type argument was not recognized."
-	errWalkError         string = "An error occurred while walking the archive."
-	errDirRemovalError   string = "An error occurred while attempting to remove a directory."
+	reasonSymlinkSkipped      string = " (existing target is a symlink)"
+
+	errTypeDetermination    string = "Unable to determine the file's archive type. Specify with the -type argument."
+	errUnrecognizedType     string = "The type specified with the -type argument was not recognized."
+	errWalkError            string = "An error occurred while walking the archive."
+	errDirRemovalError      string = "An error occurred while attempting to remove a directory."
+	errPathEscapeFmt        string = "archive entry %q escapes the target directory"
+	errManifestWriteError   string = "An error occurred while writing the install manifest."
+	errManifestError        string = "An error occurred while processing the manifest command."
+	errUnsupportedHashFmt   string = "unsupported hash algorithm %q"
+	errSymlinkEscapeFmt     string = "archive entry %q is a symlink that escapes the target directory"
+	errVerifyMismatchFmt    string = "%q failed post-install verification"
+	errFetchError           string = "An error occurred while fetching the archive."
+	errFetchStatusFmt       string = "fetching %s: unexpected status %s"
+	errDigestMismatchFmt    string = "archive failed %s verification: expected %s, got %s"
+	errManifestHashFmt      string = "--verify against an install manifest only supports --hash sha256 (the manifest's own digest algorithm), got %q"
+	errDirDigestMismatchFmt string = "directory %q no longer matches the digest recorded in its install manifest"
 )
 
 var (
 	/* CLI variables */
-	app             = kingpin.New("archive-uninstall", "A tool to remove archive contents from a target directory.")
-	verbose         = app.Flag("verbose", "Enable verbose mode.").Short('v').Bool()
-	dryRun          = app.Flag("dry-run", "Enable dry run mode. Nothing will be removed from target directory.").Bool()
-	removeDirs      = app.Flag("remove-dirs", "Enables removal of empty directories.").Bool()
-	verify          = app.Flag("verify", "Only remove verified files.").Bool()
-	noColor         = app.Flag("no-color", "Disable color output in verbose mode. ").Bool()
-	archiveTypeText = app.Flag("type", "The archive type. Determined from archive filename, if not specified.").HintOptions(".tar", ".tar.bz2", ".tar.gz", ".tar.xz", ".zip").Short('t').String()
-	archiveFile     = app.Arg("archive filename", "The filename of the archive that will be compared to the target directory.").Required().File()
-	targetDir       = app.Arg("target directory", "The target directory from which to remove files.").Required().File()
+	app         = kingpin.New("archive-uninstall", "A tool to remove archive contents from a target directory.")
+	noColor     = app.Flag("no-color", "Disable color output in verbose mode. ").Bool()
+	manifestDir = app.Flag("manifest-dir", "Directory used to store and read install manifests.").Default(defaultManifestDir()).String()
+	cacheDir    = app.Flag("cache-dir", "Directory used to cache archives fetched from a URL, keyed by URL. A cached copy is reused instead of downloading again.").String()
+
+	uninstallCmd    = app.Command("uninstall", "Remove archive contents from a target directory.").Default()
+	verbose         = uninstallCmd.Flag("verbose", "Enable verbose mode.").Short('v').Bool()
+	dryRun          = uninstallCmd.Flag("dry-run", "Enable dry run mode. Nothing will be removed from target directory.").Bool()
+	removeDirs      = uninstallCmd.Flag("remove-dirs", "Enables removal of empty directories.").Bool()
+	verify          = uninstallCmd.Flag("verify", "Only remove verified files.").Bool()
+	strictPaths     = uninstallCmd.Flag("strict-paths", "Treat archive entries whose paths escape the target directory as fatal errors, instead of silently skipping them.").Bool()
+	hashAlgo        = uninstallCmd.Flag("hash", "Hash algorithm used for --verify.").Default("sha256").Enum("sha256", "sha512", "blake3", "xxh64")
+	parallel        = uninstallCmd.Flag("parallel", "Number of archive entries to verify and remove concurrently.").Default("1").Int()
+	expectedSha256  = uninstallCmd.Flag("expected-sha256", "The archive (local or fetched) must match this SHA-256 digest.").String()
+	expectedSha512  = uninstallCmd.Flag("expected-sha512", "The archive (local or fetched) must match this SHA-512 digest.").String()
+	archiveTypeText = uninstallCmd.Flag("type", "The archive type. Determined from archive filename, if not specified.").HintOptions(".tar", ".tar.bz2", ".tar.gz", ".tar.xz", ".zip", ".rar", ".7z", ".tar.lz4", ".tar.zst", ".tar.br").Short('t').String()
+	archiveFile     = uninstallCmd.Arg("archive filename", "The archive to compare to the target directory: a local path, or an http(s):// or file:// URL.").Required().String()
+	targetDir       = uninstallCmd.Arg("target directory", "The target directory from which to remove files.").Required().File()
+
+	installCmd             = app.Command("install", "Extract an archive's contents into a target directory.")
+	installVerboseFlag     = installCmd.Flag("verbose", "Enable verbose mode.").Short('v').Bool()
+	installDryRunFlag      = installCmd.Flag("dry-run", "Enable dry run mode. Nothing will be written to the target directory.").Bool()
+	installVerifyFlag      = installCmd.Flag("verify", "Verify each extracted file's digest immediately after writing it.").Bool()
+	installStrictPathsFlag = installCmd.Flag("strict-paths", "Treat archive entries whose paths (or symlink targets) escape the target directory as fatal errors, instead of silently skipping them.").Bool()
+	installDropSetidFlag   = installCmd.Flag("drop-setid", "Strip setuid/setgid bits from extracted files.").Bool()
+	installHashAlgo        = installCmd.Flag("hash", "Hash algorithm used for --verify.").Default("sha256").Enum("sha256", "sha512", "blake3", "xxh64")
+	installWriteManifest   = installCmd.Flag("manifest", "Write an install manifest after a successful install, so a later uninstall is manifest-driven.").Default("true").Bool()
+	installExpectedSha256  = installCmd.Flag("expected-sha256", "The archive (local or fetched) must match this SHA-256 digest.").String()
+	installExpectedSha512  = installCmd.Flag("expected-sha512", "The archive (local or fetched) must match this SHA-512 digest.").String()
+	installArchiveTypeText = installCmd.Flag("type", "The archive type. Determined from archive filename, if not specified.").HintOptions(".tar", ".tar.bz2", ".tar.gz", ".tar.xz", ".zip", ".rar", ".7z", ".tar.lz4", ".tar.zst", ".tar.br").Short('t').String()
+	installArchiveFile     = installCmd.Arg("archive filename", "The archive to extract: a local path, or an http(s):// or file:// URL.").Required().String()
+	installTargetDir       = installCmd.Arg("target directory", "The target directory to extract the archive into. Created if it doesn't already exist.").Required().String()
+
+	installManifestCmd       = app.Command("install-manifest", "Write an install manifest recording what an archive installed into a target directory.")
+	installManifestArchive   = installManifestCmd.Arg("archive filename", "The filename of the archive to record a manifest for.").Required().File()
+	installManifestTargetDir = installManifestCmd.Arg("target directory", "The target directory the archive was installed into.").Required().File()
+
+	manifestCmd     = app.Command("manifest", "Inspect and manage install manifests.")
+	manifestListCmd = manifestCmd.Command("list", "List the SHA-256 digests of archives with a stored manifest.")
+	manifestShowCmd = manifestCmd.Command("show", "Print a stored manifest as JSON.")
+	manifestShowSha = manifestShowCmd.Arg("sha256", "The archive SHA-256 digest whose manifest to show.").Required().String()
+	manifestGcCmd   = manifestCmd.Command("gc", "Remove manifests whose recorded files no longer exist on disk.")
 
 	/* other variables */
-	archiveType archive.Type
+	command     string
+	archiveType archiveKind
 	directories []string
 	colorFuncs  map[string]colorFunc
 )
 
+// archiveKind is a superset of archive.Type: it covers the formats
+// github.com/calennert/archive walks directly (tar and its compressed
+// variants, zip) plus formats this repo walks itself, without requiring
+// any change upstream.
+type archiveKind int
+
+const (
+	kindTar archiveKind = iota
+	kindTarBz2
+	kindTarGz
+	kindTarXz
+	kindZip
+	kindRar
+	kind7z
+	kindTarLz4
+	kindTarZstd
+	kindTarBrotli
+)
+
+// extensionKinds lists the filename extensions handled natively by this
+// repo, i.e. the ones archive.DetermineType doesn't know about.
+var extensionKinds = map[string]archiveKind{
+	".rar":     kindRar,
+	".7z":      kind7z,
+	".tar.lz4": kindTarLz4,
+	".tar.zst": kindTarZstd,
+	".tar.br":  kindTarBrotli,
+}
+
+func kindFromArchiveType(t archive.Type) archiveKind {
+	switch t {
+	case archive.TarBz2:
+		return kindTarBz2
+	case archive.TarGz:
+		return kindTarGz
+	case archive.TarXz:
+		return kindTarXz
+	case archive.Zip:
+		return kindZip
+	default:
+		return kindTar
+	}
+}
+
+// determineArchiveType resolves the archiveKind for filename, honoring an
+// explicit typeHint (e.g. from --type) when one is given. Extensions this
+// repo walks itself (rar, 7z, tar.lz4, tar.zst, tar.br) are matched before
+// falling back to archive.DetermineType for the formats that package owns.
+func determineArchiveType(filename, typeHint string) (archiveKind, error) {
+	name := filename
+	if typeHint != "" {
+		name = typeHint
+	}
+	// filename may be an http(s):// URL (see resolveArchiveSource); strip any
+	// query string first, the same way cacheKey does, so a pinned release
+	// URL's auth token/signature doesn't get sniffed as part of the
+	// extension.
+	name = strings.SplitN(name, "?", 2)[0]
+
+	for ext, kind := range extensionKinds {
+		if strings.HasSuffix(strings.ToLower(name), ext) {
+			return kind, nil
+		}
+	}
+
+	var t archive.Type
+	var err error
+	if typeHint == "" {
+		t, err = archive.DetermineType(name)
+	} else {
+		t, err = archive.DetermineType(typeHint)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return kindFromArchiveType(t), nil
+}
+
 func init() {
 	colorFuncs = make(map[string]colorFunc)
 	colorFuncs["green"] = color.New(color.FgGreen).SprintFunc()
 	colorFuncs["red"] = color.New(color.FgRed).SprintFunc()
 	colorFuncs["cyan"] = color.New(color.FgCyan).SprintFunc()
+}
 
+// parseArgs parses os.Args and resolves archiveType for whichever command was
+// invoked. It is called explicitly from main(), rather than from init(), so
+// that importing this package (as the tests in this directory do) doesn't
+// parse the test binary's own argv as if it were ours.
+func parseArgs() {
 	app.Author("https://github.com/calennert")
 	app.Version("1.0")
 	app.UsageTemplate(CustomUsageTemplate)
-	kingpin.MustParse(app.Parse(os.Args[1:]))
+	command = kingpin.MustParse(app.Parse(os.Args[1:]))
+
+	var filename, typeHint string
+	switch command {
+	case uninstallCmd.FullCommand():
+		filename, typeHint = *archiveFile, *archiveTypeText
+	case installCmd.FullCommand():
+		filename, typeHint = *installArchiveFile, *installArchiveTypeText
+	default:
+		return
+	}
 
 	var err error
-	if *archiveTypeText == "" {
-		archiveType, err = archive.DetermineType((*archiveFile).Name())
+	if typeHint == "" {
+		archiveType, err = determineArchiveType(filename, "")
 		if err != nil {
 			app.Errorf(errTypeDetermination)
 			os.Exit(10)
 		}
 	} else {
-		archiveType, err = archive.DetermineType(*archiveTypeText)
+		archiveType, err = determineArchiveType("", typeHint)
 		if err != nil {
 			app.Errorf(errUnrecognizedType)
 			os.Exit(12)
@@ -117,19 +258,111 @@ func printCaption(value bool) string {
 	return red("No")
 }
 
+// resolvePath joins member onto targetRoot and confirms the result does not
+// escape targetRoot, either directly (e.g. via a "../" member name) or via a
+// symlink somewhere along the path. It returns the resolved path and whether
+// it escapes targetRoot.
+func resolvePath(targetRoot, member string) (string, bool, error) {
+	joined := filepath.Clean(filepath.Join(targetRoot, member))
+	sep := string(os.PathSeparator)
+
+	if joined != targetRoot && !strings.HasPrefix(joined, targetRoot+sep) {
+		return joined, true, nil
+	}
+
+	resolved, err := resolveExistingPrefix(joined)
+	if err != nil {
+		return joined, false, err
+	}
+
+	if resolved != targetRoot && !strings.HasPrefix(resolved, targetRoot+sep) {
+		return resolved, true, nil
+	}
+
+	return resolved, false, nil
+}
+
+// resolveExistingPrefix resolves symlinks along path's nearest existing
+// ancestor, then rejoins whatever suffix doesn't exist yet. A plain
+// filepath.EvalSymlinks(path) fails with NotExist as soon as the leaf
+// doesn't exist, even when an earlier component does and is a symlink
+// pointing outside targetRoot — exactly the tar-slip/zip-slip pattern of a
+// symlink entry followed by a regular file written through it. Walking up
+// to the nearest ancestor that does exist means that symlink still gets
+// resolved and checked.
+func resolveExistingPrefix(path string) (string, error) {
+	suffix := ""
+	current := path
+
+	for {
+		resolved, err := filepath.EvalSymlinks(current)
+		if err == nil {
+			if suffix == "" {
+				return resolved, nil
+			}
+			return filepath.Join(resolved, suffix), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+
+		parent := filepath.Dir(current)
+		if parent == current {
+			// Reached the root of the filesystem without finding an
+			// existing component; there is nothing left to resolve.
+			return filepath.Join(current, suffix), nil
+		}
+		suffix = filepath.Join(filepath.Base(current), suffix)
+		current = parent
+	}
+}
+
 func removeFromTargetDir(filename string, targetPath *os.File, verifyFunc verifyCallback) error {
-	path := filepath.Join(targetPath.Name(), filename)
-	file, _ := os.Open(path)
+	targetRoot := filepath.Clean(targetPath.Name())
+
+	path, escaped, err := resolvePath(targetRoot, filename)
+	if err != nil {
+		return err
+	}
 
+	var output strings.Builder
 	if *verbose {
-		fmt.Printf(fmtFilename, cyan(filename))
-		fmt.Printf(fmtExists, printCaption((file != nil)))
+		fmt.Fprintf(&output, fmtFilename, cyan(filename))
+	}
+
+	if escaped {
+		if *verbose {
+			fmt.Fprintf(&output, fmtEscaped, printCaption(true))
+			printVerbose(&output)
+		}
+		if *strictPaths {
+			return fmt.Errorf(errPathEscapeFmt, filename)
+		}
+		return nil
+	}
+
+	info, statErr := os.Lstat(path)
+	exists := statErr == nil
+
+	if *verbose {
+		fmt.Fprintf(&output, fmtExists, printCaption(exists))
 	}
 
 	removed := false
 	reason := ""
 
-	if file != nil {
+	switch {
+	case !exists:
+		reason = reasonFileNotFound
+	case info.Mode()&os.ModeSymlink != 0:
+		reason = reasonSymlinkSkipped
+	default:
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
 		verified, err := verifyFunc(file)
 		if err != nil {
 			return err
@@ -137,8 +370,7 @@ func removeFromTargetDir(filename string, targetPath *os.File, verifyFunc verify
 
 		if verified {
 			if !*dryRun {
-				err = os.Remove(file.Name())
-				if err != nil {
+				if err := os.Remove(path); err != nil {
 					return err
 				}
 				removed = true
@@ -146,75 +378,137 @@ func removeFromTargetDir(filename string, targetPath *os.File, verifyFunc verify
 		} else {
 			reason = reasonVerificationFailure
 		}
-	} else {
-		reason = reasonFileNotFound
 	}
 
 	if *verbose {
-		fmt.Printf(fmtRemoved, printCaption(removed), reason)
+		fmt.Fprintf(&output, fmtRemoved, printCaption(removed), reason)
+		printVerbose(&output)
 	}
 	return nil
 }
 
-func tarCallback(reader *tar.Reader, header *tar.Header) error {
-	if header.FileInfo().IsDir() {
-		addDirectory(header.Name)
-		return nil
+// printVerbose flushes a removeFromTargetDir call's buffered output as a
+// single atomic write, so concurrent workers (see --parallel) don't
+// interleave their lines.
+func printVerbose(output *strings.Builder) {
+	printMu.Lock()
+	defer printMu.Unlock()
+	fmt.Print(output.String())
+}
+
+// Entry is a format-agnostic view of a single archive member. It unifies
+// *tar.Header and *zip.File so entryCallback doesn't need to know which
+// archive backend produced it.
+type Entry struct {
+	Name       string
+	IsDir      bool
+	IsSymlink  bool
+	LinkTarget string
+	Mode       os.FileMode
+	Open       func() (io.Reader, error)
+}
+
+func tarEntry(reader *tar.Reader, header *tar.Header) Entry {
+	return Entry{
+		Name:       header.Name,
+		IsDir:      header.FileInfo().IsDir(),
+		IsSymlink:  header.Typeflag == tar.TypeSymlink,
+		LinkTarget: header.Linkname,
+		Mode:       header.FileInfo().Mode(),
+		Open:       func() (io.Reader, error) { return reader, nil },
 	}
+}
 
-	verifyFunc := func(file *os.File) (bool, error) {
-		if *verify {
-			data, err := ioutil.ReadAll(file)
-			if err != nil {
-				return false, err
-			}
-			targetSha256 := sha256.Sum256(data)
+func zipEntry(file *zip.File) Entry {
+	info := file.FileInfo()
+	return Entry{
+		Name:      file.Name,
+		IsDir:     info.IsDir(),
+		IsSymlink: info.Mode()&os.ModeSymlink != 0,
+		Mode:      info.Mode(),
+		Open:      func() (io.Reader, error) { return file.Open() },
+	}
+}
 
-			data, err = ioutil.ReadAll(reader)
-			if err != nil {
-				return false, err
-			}
-			archiveSha256 := sha256.Sum256(data)
+// verifyPool bounds how many entries are verified and removed concurrently.
+// It is sized from --parallel in runUninstall before the archive is walked.
+var (
+	verifyPool    chan struct{}
+	verifyWG      sync.WaitGroup
+	verifyErrOnce sync.Once
+	verifyErr     error
+	printMu       sync.Mutex
+)
 
-			return (targetSha256 == archiveSha256), nil
-		}
-		return true, nil
+func initVerifyPool(n int) {
+	if n < 1 {
+		n = 1
 	}
+	verifyPool = make(chan struct{}, n)
+}
 
-	return removeFromTargetDir(header.Name, *targetDir, verifyFunc)
+func recordVerifyErr(err error) {
+	if err == nil {
+		return
+	}
+	verifyErrOnce.Do(func() { verifyErr = err })
 }
 
-func zipCallback(file *zip.File) error {
-	if file.FileInfo().IsDir() {
-		addDirectory(file.Name)
+// entryCallback hashes the archive side of an entry on the walker's own
+// goroutine, since tar.Reader only supports sequential reads, then hands the
+// (possibly expensive) target-file hash and removal off to a bounded worker
+// so verification of large archives scales across cores.
+func entryCallback(entry Entry) error {
+	if entry.IsDir {
+		targetRoot := filepath.Clean((*targetDir).Name())
+		_, escaped, err := resolvePath(targetRoot, entry.Name)
+		if err != nil {
+			return err
+		}
+		if escaped {
+			if *strictPaths {
+				return fmt.Errorf(errPathEscapeFmt, entry.Name)
+			}
+			return nil
+		}
+		addDirectory(entry.Name)
 		return nil
 	}
 
-	verifyFunc := func(targetFile *os.File) (bool, error) {
-		if *verify {
-			data, err := ioutil.ReadAll(targetFile)
-			if err != nil {
-				return false, err
-			}
-			targetSha256 := sha256.Sum256(data)
+	var archiveDigest string
+	if *verify {
+		reader, err := entry.Open()
+		if err != nil {
+			return err
+		}
 
-			var reader io.ReadCloser
-			reader, err = file.Open()
-			if err != nil {
-				return false, err
-			}
-			data, err = ioutil.ReadAll(reader)
-			if err != nil {
-				return false, err
-			}
-			archiveSha256 := sha256.Sum256(data)
+		archiveDigest, err = hashReaderWith(reader, *hashAlgo)
+		if err != nil {
+			return err
+		}
+	}
+
+	verifyFunc := func(targetFile *os.File) (bool, error) {
+		if !*verify {
+			return true, nil
+		}
 
-			return (targetSha256 == archiveSha256), nil
+		targetDigest, err := hashReaderWith(targetFile, *hashAlgo)
+		if err != nil {
+			return false, err
 		}
-		return true, nil
+		return targetDigest == archiveDigest, nil
 	}
 
-	return removeFromTargetDir(file.Name, *targetDir, verifyFunc)
+	verifyPool <- struct{}{}
+	verifyWG.Add(1)
+	go func() {
+		defer verifyWG.Done()
+		defer func() { <-verifyPool }()
+		recordVerifyErr(removeFromTargetDir(entry.Name, *targetDir, verifyFunc))
+	}()
+
+	return nil
 }
 
 func addDirectory(dirName string) {
@@ -269,28 +563,103 @@ func removeEmptyDirectories() {
 	}
 }
 
-func main() {
-	var err error
-	archiveFilename := (*archiveFile).Name()
+// walkers maps each supported archiveKind to the function that streams its
+// entries, as Entry values, into a caller-supplied callback. Adding a new
+// archive format that github.com/calennert/archive already understands is a
+// matter of adding an entry here and to kindFromArchiveType; formats this
+// repo reads itself (see formats.go) are registered the same way.
+var walkers = map[archiveKind]func(filename string, cb func(Entry) error) error{
+	kindTar: func(filename string, cb func(Entry) error) error {
+		return archive.WalkTar(filename, func(r *tar.Reader, h *tar.Header) error { return cb(tarEntry(r, h)) })
+	},
+	kindTarBz2: func(filename string, cb func(Entry) error) error {
+		return archive.WalkTarBzip2(filename, func(r *tar.Reader, h *tar.Header) error { return cb(tarEntry(r, h)) })
+	},
+	kindTarGz: func(filename string, cb func(Entry) error) error {
+		return archive.WalkTarGz(filename, func(r *tar.Reader, h *tar.Header) error { return cb(tarEntry(r, h)) })
+	},
+	kindTarXz: func(filename string, cb func(Entry) error) error {
+		return archive.WalkTarXz(filename, func(r *tar.Reader, h *tar.Header) error { return cb(tarEntry(r, h)) })
+	},
+	kindZip: func(filename string, cb func(Entry) error) error {
+		return archive.WalkZip(filename, func(f *zip.File) error { return cb(zipEntry(f)) })
+	},
+	kindRar:       walkRar,
+	kind7z:        walk7z,
+	kindTarLz4:    walkTarLz4,
+	kindTarZstd:   walkTarZstd,
+	kindTarBrotli: walkTarBrotli,
+}
 
-	switch archiveType {
-	case archive.Tar:
-		err = archive.WalkTar(archiveFilename, tarCallback)
-	case archive.TarBz2:
-		err = archive.WalkTarBzip2(archiveFilename, tarCallback)
-	case archive.TarGz:
-		err = archive.WalkTarGz(archiveFilename, tarCallback)
-	case archive.TarXz:
-		err = archive.WalkTarXz(archiveFilename, tarCallback)
-	case archive.Zip:
-		err = archive.WalkZip(archiveFilename, zipCallback)
+func runUninstall() {
+	archiveFilename, err := resolveArchiveSource(*archiveFile, *cacheDir, *expectedSha256, *expectedSha512)
+	app.FatalIfError(err, errFetchError)
+	initVerifyPool(*parallel)
+
+	if archiveSha256, err := hashFile(archiveFilename); err == nil {
+		if m, err := loadManifest(*manifestDir, archiveSha256); err == nil {
+			if *verify && *hashAlgo != "" && *hashAlgo != "sha256" {
+				app.Errorf(errManifestHashFmt, *hashAlgo)
+				os.Exit(13)
+			}
+
+			walkErr := removeUsingManifest(m)
+			verifyWG.Wait()
+			if walkErr != nil {
+				app.FatalIfError(walkErr, errWalkError)
+			}
+			app.FatalIfError(verifyErr, errWalkError)
+
+			if *removeDirs {
+				removeEmptyDirectories()
+			}
+			return
+		}
 	}
 
-	if err != nil {
-		app.FatalIfError(err, errWalkError)
+	walk, ok := walkers[archiveType]
+	if !ok {
+		app.Errorf(errUnrecognizedType)
+		os.Exit(12)
 	}
 
+	walkErr := walk(archiveFilename, entryCallback)
+	verifyWG.Wait()
+	if walkErr != nil {
+		app.FatalIfError(walkErr, errWalkError)
+	}
+	app.FatalIfError(verifyErr, errWalkError)
+
 	if *removeDirs {
 		removeEmptyDirectories()
 	}
 }
+
+func runInstallManifest() {
+	m, err := generateManifest((*installManifestArchive).Name(), (*installManifestTargetDir).Name())
+	app.FatalIfError(err, errWalkError)
+	app.FatalIfError(writeManifestFile(*manifestDir, m), errManifestWriteError)
+}
+
+func main() {
+	parseArgs()
+
+	var err error
+
+	switch command {
+	case uninstallCmd.FullCommand():
+		runUninstall()
+	case installCmd.FullCommand():
+		runInstall()
+	case installManifestCmd.FullCommand():
+		runInstallManifest()
+	case manifestListCmd.FullCommand():
+		err = runManifestList(*manifestDir)
+	case manifestShowCmd.FullCommand():
+		err = runManifestShow(*manifestDir, *manifestShowSha)
+	case manifestGcCmd.FullCommand():
+		err = runManifestGC(*manifestDir)
+	}
+
+	app.FatalIfError(err, errManifestError)
+}
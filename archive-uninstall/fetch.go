@@ -0,0 +1,164 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// resolveArchiveSource turns source into a local path that archive.Walk*
+// can read: a plain local path and a file:// URL are used (and digest
+// checked) in place, while an http(s):// URL is downloaded first. cacheDir,
+// when non-empty, lets repeated uninstalls/installs of the same URL skip
+// the download.
+func resolveArchiveSource(source, cacheDir, expectedSha256, expectedSha512 string) (string, error) {
+	u, err := url.Parse(source)
+	if err != nil || u.Scheme == "" {
+		return verifyLocalArchive(source, expectedSha256, expectedSha512)
+	}
+
+	switch u.Scheme {
+	case "file":
+		return verifyLocalArchive(u.Path, expectedSha256, expectedSha512)
+	case "http", "https":
+		return fetchRemoteArchive(source, cacheDir, expectedSha256, expectedSha512)
+	default:
+		return verifyLocalArchive(source, expectedSha256, expectedSha512)
+	}
+}
+
+func verifyLocalArchive(path, expectedSha256, expectedSha512 string) (string, error) {
+	if err := verifyDigests(path, expectedSha256, expectedSha512); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// cacheKey names a cached download after the SHA-256 of its URL (so the
+// cache doesn't have to deal with arbitrary URL characters), keeping the
+// original extension so DetermineType still works on the cached path.
+func cacheKey(rawURL string) string {
+	sum := sha256.Sum256([]byte(rawURL))
+	return hex.EncodeToString(sum[:]) + archiveExt(rawURL)
+}
+
+// archiveExt returns rawURL's extension, ignoring any query string and
+// keeping a compound ".tar.*" extension whole (".tar.zst", not ".zst").
+func archiveExt(rawURL string) string {
+	name := filepath.Base(strings.SplitN(rawURL, "?", 2)[0])
+	ext := filepath.Ext(name)
+	if stem := strings.TrimSuffix(name, ext); strings.HasSuffix(strings.ToLower(stem), ".tar") {
+		return name[len(stem)-len(".tar"):]
+	}
+	return ext
+}
+
+func fetchRemoteArchive(rawURL, cacheDir, expectedSha256, expectedSha512 string) (string, error) {
+	if cacheDir != "" {
+		cachePath := filepath.Join(cacheDir, cacheKey(rawURL))
+		if _, err := os.Stat(cachePath); err == nil {
+			if err := verifyDigests(cachePath, expectedSha256, expectedSha512); err != nil {
+				return "", err
+			}
+			return cachePath, nil
+		}
+	}
+
+	resp, err := http.Get(rawURL)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf(errFetchStatusFmt, rawURL, resp.Status)
+	}
+
+	var dest string
+	if cacheDir != "" {
+		if err := os.MkdirAll(cacheDir, 0o755); err != nil {
+			return "", err
+		}
+		dest = filepath.Join(cacheDir, cacheKey(rawURL))
+	} else {
+		tmp, err := ioutil.TempFile("", "archive-uninstall-*"+archiveExt(rawURL))
+		if err != nil {
+			return "", err
+		}
+		dest = tmp.Name()
+		tmp.Close()
+	}
+
+	sha256Hasher := sha256.New()
+	sha512Hasher := sha512.New()
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return "", err
+	}
+
+	_, copyErr := io.Copy(io.MultiWriter(out, sha256Hasher, sha512Hasher), resp.Body)
+	closeErr := out.Close()
+
+	if copyErr != nil {
+		os.Remove(dest)
+		return "", copyErr
+	}
+	if closeErr != nil {
+		os.Remove(dest)
+		return "", closeErr
+	}
+
+	if err := checkDigest("sha256", expectedSha256, hex.EncodeToString(sha256Hasher.Sum(nil))); err != nil {
+		os.Remove(dest)
+		return "", err
+	}
+	if err := checkDigest("sha512", expectedSha512, hex.EncodeToString(sha512Hasher.Sum(nil))); err != nil {
+		os.Remove(dest)
+		return "", err
+	}
+
+	return dest, nil
+}
+
+func verifyDigests(path, expectedSha256, expectedSha512 string) error {
+	if expectedSha256 != "" {
+		actual, err := hashFileWith(path, "sha256")
+		if err != nil {
+			return err
+		}
+		if err := checkDigest("sha256", expectedSha256, actual); err != nil {
+			return err
+		}
+	}
+
+	if expectedSha512 != "" {
+		actual, err := hashFileWith(path, "sha512")
+		if err != nil {
+			return err
+		}
+		if err := checkDigest("sha512", expectedSha512, actual); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func checkDigest(algo, expected, actual string) error {
+	if expected == "" {
+		return nil
+	}
+	if !strings.EqualFold(expected, actual) {
+		return fmt.Errorf(errDigestMismatchFmt, algo, expected, actual)
+	}
+	return nil
+}
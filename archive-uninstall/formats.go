@@ -0,0 +1,135 @@
+package main
+
+import (
+	"archive/tar"
+	"io"
+	"os"
+
+	"github.com/andybalholm/brotli"
+	"github.com/bodgit/sevenzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/nwaples/rardecode"
+	"github.com/pierrec/lz4/v4"
+)
+
+// walkRar streams a rar archive's entries, as Entry values, into cb. Unlike
+// the tar/zip backends, rardecode's Reader is both the directory walker and
+// the current entry's io.Reader, so rarEntry's Open just returns rc itself.
+func walkRar(filename string, cb func(Entry) error) error {
+	rc, err := rardecode.OpenReader(filename, "")
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	for {
+		header, err := rc.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := cb(rarEntry(rc, header)); err != nil {
+			return err
+		}
+	}
+}
+
+// rarEntry adapts a rardecode header into an Entry. Like zip, rar has no
+// dedicated symlink-target field; the target is the entry's own content, so
+// LinkTarget is left for entryLinkTarget (install.go) to read from Open.
+func rarEntry(rc *rardecode.ReadCloser, header *rardecode.FileHeader) Entry {
+	return Entry{
+		Name:      header.Name,
+		IsDir:     header.IsDir,
+		IsSymlink: header.Mode()&os.ModeSymlink != 0,
+		Mode:      header.Mode(),
+		Open:      func() (io.Reader, error) { return rc, nil },
+	}
+}
+
+// walk7z streams a 7z archive's entries into cb, mirroring zipEntry's shape
+// since sevenzip.File follows the same fs.FileInfo-backed API as zip.File.
+func walk7z(filename string, cb func(Entry) error) error {
+	rc, err := sevenzip.OpenReader(filename)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	for _, f := range rc.File {
+		if err := cb(sevenZipEntry(f)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sevenZipEntry adapts a sevenzip.File into an Entry. As with zip and rar,
+// 7z has no dedicated symlink-target field; the target is the entry's own
+// content, so LinkTarget is left for entryLinkTarget (install.go) to read
+// from Open.
+func sevenZipEntry(f *sevenzip.File) Entry {
+	info := f.FileInfo()
+	return Entry{
+		Name:      f.Name,
+		IsDir:     info.IsDir(),
+		IsSymlink: info.Mode()&os.ModeSymlink != 0,
+		Mode:      info.Mode(),
+		Open:      func() (io.Reader, error) { return f.Open() },
+	}
+}
+
+// walkTarLz4 decompresses an lz4-compressed tar stream and walks it exactly
+// like the tar backends in the walkers map (archive-uninstall.go).
+func walkTarLz4(filename string, cb func(Entry) error) error {
+	return walkCompressedTar(filename, func(r io.Reader) io.Reader { return lz4.NewReader(r) }, cb)
+}
+
+// walkTarZstd decompresses a zstd-compressed tar stream and walks it.
+func walkTarZstd(filename string, cb func(Entry) error) error {
+	return walkCompressedTar(filename, func(r io.Reader) io.Reader {
+		d, err := zstd.NewReader(r)
+		if err != nil {
+			return errReader{err}
+		}
+		return d
+	}, cb)
+}
+
+// walkTarBrotli decompresses a brotli-compressed tar stream and walks it.
+func walkTarBrotli(filename string, cb func(Entry) error) error {
+	return walkCompressedTar(filename, func(r io.Reader) io.Reader { return brotli.NewReader(r) }, cb)
+}
+
+// errReader is an io.Reader that always returns err, used to thread a setup
+// error (e.g. from zstd.NewReader) through walkCompressedTar's single
+// decompress-then-read pipeline.
+type errReader struct{ err error }
+
+func (e errReader) Read([]byte) (int, error) { return 0, e.err }
+
+func walkCompressedTar(filename string, decompress func(io.Reader) io.Reader, cb func(Entry) error) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := tar.NewReader(decompress(file))
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := cb(tarEntry(reader, header)); err != nil {
+			return err
+		}
+	}
+}
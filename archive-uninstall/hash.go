@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"sync"
+
+	"github.com/cespare/xxhash/v2"
+	"lukechampine.com/blake3"
+)
+
+// copyBufPool pools the buffers io.CopyBuffer needs, the same way
+// docker/pkg/pools does, so hashing many files concurrently (e.g. under
+// --parallel) doesn't allocate a fresh 32KB buffer per call.
+var copyBufPool = sync.Pool{
+	New: func() interface{} { return make([]byte, 32*1024) },
+}
+
+func newHasher(algo string) (hash.Hash, error) {
+	switch algo {
+	case "", "sha256":
+		return sha256.New(), nil
+	case "sha512":
+		return sha512.New(), nil
+	case "blake3":
+		return blake3.New(32, nil), nil
+	case "xxh64":
+		return xxhash.New(), nil
+	default:
+		return nil, fmt.Errorf(errUnsupportedHashFmt, algo)
+	}
+}
+
+// hashReaderWith streams r through algo's digest, rather than reading it
+// fully into memory first, so --verify scales to multi-GB archives and
+// target files.
+func hashReaderWith(r io.Reader, algo string) (string, error) {
+	hasher, err := newHasher(algo)
+	if err != nil {
+		return "", err
+	}
+
+	buf := copyBufPool.Get().([]byte)
+	defer copyBufPool.Put(buf)
+
+	// Hide r's own WriteTo (e.g. *os.File's) so CopyBuffer really uses buf.
+	if _, err := io.CopyBuffer(hasher, struct{ io.Reader }{r}, buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
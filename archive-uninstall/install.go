@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// entryLinkTarget returns the path a symlink entry points at. tar stores it
+// directly on the header (Entry.LinkTarget); zip has no such field, so the
+// target is the entry's own content instead.
+func entryLinkTarget(entry Entry) (string, error) {
+	if entry.LinkTarget != "" {
+		return entry.LinkTarget, nil
+	}
+
+	reader, err := entry.Open()
+	if err != nil {
+		return "", err
+	}
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// symlinkTargetEscapes reports whether linkTarget, resolved relative to
+// linkPath's directory, would land outside targetRoot. An absolute
+// linkTarget is always reported as escaping, even one inside targetRoot.
+func symlinkTargetEscapes(targetRoot, linkPath, linkTarget string) bool {
+	if filepath.IsAbs(linkTarget) {
+		return true
+	}
+
+	resolved := filepath.Clean(filepath.Join(filepath.Dir(linkPath), linkTarget))
+	sep := string(os.PathSeparator)
+	return resolved != targetRoot && !strings.HasPrefix(resolved, targetRoot+sep)
+}
+
+// installEntryCallback extracts a single archive Entry under targetRoot. It
+// reuses resolvePath so install rejects the same path-traversal attempts
+// uninstall does, and additionally refuses symlink entries whose target
+// would land outside targetRoot.
+func installEntryCallback(targetRoot string) func(Entry) error {
+	return func(entry Entry) error {
+		path, escaped, err := resolvePath(targetRoot, entry.Name)
+		if err != nil {
+			return err
+		}
+
+		if *installVerboseFlag {
+			fmt.Printf(fmtFilename, cyan(entry.Name))
+		}
+
+		if escaped {
+			if *installVerboseFlag {
+				fmt.Printf(fmtEscaped, printCaption(true))
+			}
+			if *installStrictPathsFlag {
+				return fmt.Errorf(errPathEscapeFmt, entry.Name)
+			}
+			return nil
+		}
+
+		switch {
+		case entry.IsDir:
+			return installDir(path, entry.Mode)
+		case entry.IsSymlink:
+			return installSymlink(targetRoot, path, entry)
+		default:
+			return installFile(path, entry)
+		}
+	}
+}
+
+func installDir(path string, mode os.FileMode) error {
+	if *installDryRunFlag {
+		return nil
+	}
+	return os.MkdirAll(path, mode.Perm()|0o700)
+}
+
+func installSymlink(targetRoot, path string, entry Entry) error {
+	linkTarget, err := entryLinkTarget(entry)
+	if err != nil {
+		return err
+	}
+
+	if symlinkTargetEscapes(targetRoot, path, linkTarget) {
+		if *installStrictPathsFlag {
+			return fmt.Errorf(errSymlinkEscapeFmt, entry.Name)
+		}
+		return nil
+	}
+
+	if *installDryRunFlag {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	os.Remove(path)
+	return os.Symlink(linkTarget, path)
+}
+
+func installFile(path string, entry Entry) error {
+	mode := entry.Mode & os.ModePerm
+	if !*installDropSetidFlag {
+		mode |= entry.Mode & (os.ModeSetuid | os.ModeSetgid)
+	}
+
+	if *installDryRunFlag {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	reader, err := entry.Open()
+	if err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	writer := io.Writer(out)
+	var hasher hash.Hash
+	if *installVerifyFlag {
+		hasher, err = newHasher(*installHashAlgo)
+		if err != nil {
+			return err
+		}
+		writer = io.MultiWriter(out, hasher)
+	}
+
+	if _, err := io.Copy(writer, reader); err != nil {
+		return err
+	}
+
+	if hasher == nil {
+		return nil
+	}
+
+	expected := hex.EncodeToString(hasher.Sum(nil))
+	actual, err := hashFileWith(path, *installHashAlgo)
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		return fmt.Errorf(errVerifyMismatchFmt, path)
+	}
+	return nil
+}
+
+func hashFileWith(filename, algo string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	return hashReaderWith(file, algo)
+}
+
+func runInstall() {
+	archiveFilename, err := resolveArchiveSource(*installArchiveFile, *cacheDir, *installExpectedSha256, *installExpectedSha512)
+	app.FatalIfError(err, errFetchError)
+	targetRoot := filepath.Clean(*installTargetDir)
+
+	if !*installDryRunFlag {
+		if err := os.MkdirAll(targetRoot, 0o755); err != nil {
+			app.FatalIfError(err, errWalkError)
+		}
+	}
+
+	walk, ok := walkers[archiveType]
+	if !ok {
+		app.Errorf(errUnrecognizedType)
+		os.Exit(12)
+	}
+
+	if err := walk(archiveFilename, installEntryCallback(targetRoot)); err != nil {
+		app.FatalIfError(err, errWalkError)
+	}
+
+	if *installDryRunFlag || !*installWriteManifest {
+		return
+	}
+
+	m, err := generateManifest(archiveFilename, targetRoot)
+	app.FatalIfError(err, errManifestWriteError)
+	app.FatalIfError(writeManifestFile(*manifestDir, m), errManifestWriteError)
+}
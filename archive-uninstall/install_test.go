@@ -0,0 +1,40 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestInstallEntryCallbackRejectsSymlinkEscapeThroughMissingLeaf reproduces
+// the tar-slip pattern installEntryCallback must reject: an entry path that
+// runs through a pre-existing symlink (as another archive entry would have
+// created) into a file that does not exist yet at the real destination.
+// installEntryCallback relies entirely on resolvePath for this, so it shares
+// whatever escape detection resolvePath has.
+func TestInstallEntryCallbackRejectsSymlinkEscapeThroughMissingLeaf(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+
+	if err := os.Symlink(outside, filepath.Join(root, "legit")); err != nil {
+		t.Fatal(err)
+	}
+
+	savedStrict, savedDryRun, savedVerbose := *installStrictPathsFlag, *installDryRunFlag, *installVerboseFlag
+	t.Cleanup(func() {
+		*installStrictPathsFlag, *installDryRunFlag, *installVerboseFlag = savedStrict, savedDryRun, savedVerbose
+	})
+	*installDryRunFlag = true
+	*installVerboseFlag = false
+
+	member := filepath.Join("legit", "newfile.txt")
+
+	*installStrictPathsFlag = true
+	if err := installEntryCallback(root)(Entry{Name: member, Mode: 0o644}); err == nil {
+		t.Error("strict mode should reject an entry written through a symlink that escapes root, got nil error")
+	}
+
+	if _, err := os.Lstat(filepath.Join(outside, "newfile.txt")); err == nil {
+		t.Error("installEntryCallback must not have written through the escaping symlink")
+	}
+}
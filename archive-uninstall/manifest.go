@@ -0,0 +1,379 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ManifestEntry records the identity of a single file an archive installed:
+// its path, cleaned and made relative to TargetDir (so it is stable
+// regardless of how the archive itself named the entry), the SHA-256 digest
+// of the installed (not the archived) bytes, and its mode bits.
+type ManifestEntry struct {
+	Path   string      `json:"path"`
+	Sha256 string      `json:"sha256"`
+	Mode   os.FileMode `json:"mode"`
+}
+
+// ManifestDirectory records a directory an archive installed, keyed the same
+// way as ManifestEntry.Path, plus a recursive digest over every file's path
+// and SHA-256 beneath it (see dirDigest), so --verify can assert that the
+// files recorded under a directory are all still there with their recorded
+// contents. Checking it re-hashes every one of those files on disk.
+type ManifestDirectory struct {
+	Path   string `json:"path"`
+	Sha256 string `json:"sha256"`
+}
+
+// Manifest is the content-addressable record of what an archive installed
+// into a target directory. It is keyed by the archive's own SHA-256 digest
+// so an uninstall can find it again without walking the archive; the
+// archive file is still read once to compute that digest.
+type Manifest struct {
+	ArchiveSha256 string              `json:"archive_sha256"`
+	TargetDir     string              `json:"target_dir"`
+	Files         []ManifestEntry     `json:"files"`
+	Directories   []ManifestDirectory `json:"directories"`
+}
+
+func defaultManifestDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".archive-uninstall", "manifests")
+	}
+	return filepath.Join(home, ".archive-uninstall", "manifests")
+}
+
+func manifestPath(dir, archiveSha256 string) string {
+	return filepath.Join(dir, archiveSha256+".json")
+}
+
+// hashReader streams r through a SHA-256 digest rather than reading it
+// fully into memory, so verification scales to multi-GB files.
+func hashReader(r io.Reader) (string, error) {
+	hasher := sha256.New()
+
+	buf := copyBufPool.Get().([]byte)
+	defer copyBufPool.Put(buf)
+
+	// Hide r's own WriteTo (e.g. *os.File's) so CopyBuffer really uses buf.
+	if _, err := io.CopyBuffer(hasher, struct{ io.Reader }{r}, buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+func hashFile(filename string) (string, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	return hashReader(file)
+}
+
+func loadManifest(dir, archiveSha256 string) (*Manifest, error) {
+	data, err := ioutil.ReadFile(manifestPath(dir, archiveSha256))
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func writeManifestFile(dir string, m *Manifest) error {
+	sort.Slice(m.Files, func(i, j int) bool { return m.Files[i].Path < m.Files[j].Path })
+	sort.Slice(m.Directories, func(i, j int) bool { return m.Directories[i].Path < m.Directories[j].Path })
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(manifestPath(dir, m.ArchiveSha256), data, 0o644)
+}
+
+// canonicalPath resolves member against targetRoot and returns it as a
+// cleaned path relative to targetRoot, so the manifest keys entries by a
+// stable path rather than however the archive itself happened to name them
+// (e.g. a leading "./" or redundant ".." segments that still land inside
+// targetRoot).
+func canonicalPath(targetRoot, member string) (string, bool, error) {
+	resolved, escaped, err := resolvePath(targetRoot, member)
+	if err != nil || escaped {
+		return "", escaped, err
+	}
+
+	rel, err := filepath.Rel(targetRoot, resolved)
+	if err != nil {
+		return "", false, err
+	}
+	return rel, false, nil
+}
+
+// manifestEntryCallback adapts an Entry walk into a Manifest: directories are
+// recorded by their canonical path, files are recorded with the digest of
+// the corresponding file already present under targetRoot (the installed
+// bytes, not the archived ones), so a later --verify can detect drift
+// either way.
+func manifestEntryCallback(m *Manifest, targetRoot string) func(Entry) error {
+	return func(entry Entry) error {
+		relPath, escaped, err := canonicalPath(targetRoot, entry.Name)
+		if err != nil {
+			return err
+		}
+		if escaped {
+			return fmt.Errorf(errPathEscapeFmt, entry.Name)
+		}
+
+		if entry.IsDir {
+			m.Directories = append(m.Directories, ManifestDirectory{Path: relPath})
+			return nil
+		}
+
+		file, err := os.Open(filepath.Join(targetRoot, relPath))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		info, err := file.Stat()
+		if err != nil {
+			return err
+		}
+
+		sha, err := hashReader(file)
+		if err != nil {
+			return err
+		}
+
+		m.Files = append(m.Files, ManifestEntry{Path: relPath, Sha256: sha, Mode: info.Mode()})
+		return nil
+	}
+}
+
+// dirDigest hashes the sorted (path, sha256) pairs of every file in files
+// that falls under dir (dir itself, or anything below it), so two
+// directories have the same digest exactly when they contain the same files
+// with the same content.
+func dirDigest(dir string, files []ManifestEntry) string {
+	var members []ManifestEntry
+	prefix := dir + string(os.PathSeparator)
+	if dir == "." {
+		prefix = ""
+	}
+	for _, f := range files {
+		if f.Path == dir || strings.HasPrefix(f.Path, prefix) {
+			members = append(members, f)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Path < members[j].Path })
+
+	h := sha256.New()
+	for _, f := range members {
+		fmt.Fprintf(h, "%s  %s\n", f.Sha256, f.Path)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// generateManifest walks archiveFilename and records, for every entry,
+// the digest of the matching file under targetDirPath, plus a recursive
+// digest for every directory so --verify can assert directory contents
+// match what was installed.
+func generateManifest(archiveFilename, targetDirPath string) (*Manifest, error) {
+	archiveType, err := determineArchiveType(archiveFilename, "")
+	if err != nil {
+		return nil, err
+	}
+
+	walk, ok := walkers[archiveType]
+	if !ok {
+		return nil, fmt.Errorf(errUnrecognizedType)
+	}
+
+	archiveSha256, err := hashFile(archiveFilename)
+	if err != nil {
+		return nil, err
+	}
+
+	targetRoot := filepath.Clean(targetDirPath)
+	m := &Manifest{ArchiveSha256: archiveSha256, TargetDir: targetRoot}
+
+	if err := walk(archiveFilename, manifestEntryCallback(m, targetRoot)); err != nil {
+		return nil, err
+	}
+
+	for i, d := range m.Directories {
+		m.Directories[i].Sha256 = dirDigest(d.Path, m.Files)
+	}
+
+	return m, nil
+}
+
+// verifyManifestDirectories recomputes each of m.Directories' digests from
+// the files currently on disk and compares them against the digest recorded
+// at install time, before anything is removed. This is what lets --verify
+// assert a directory's contents as a whole, not just file-by-file. Only the
+// files the manifest recorded are hashed: a file added to a directory since
+// the manifest was written does not change its digest and is not detected.
+func verifyManifestDirectories(m *Manifest, targetRoot string) error {
+	var currentFiles []ManifestEntry
+	for _, f := range m.Files {
+		sha, err := hashFile(filepath.Join(targetRoot, f.Path))
+		if err != nil {
+			return err
+		}
+		currentFiles = append(currentFiles, ManifestEntry{Path: f.Path, Sha256: sha})
+	}
+
+	for _, d := range m.Directories {
+		if dirDigest(d.Path, currentFiles) != d.Sha256 {
+			return fmt.Errorf(errDirDigestMismatchFmt, d.Path)
+		}
+	}
+	return nil
+}
+
+// removeUsingManifest removes every file recorded in m from the target
+// directory given on the command line (*targetDir, not m.TargetDir),
+// verifying against the manifest's stored digests rather than recomputing
+// them from the archive. runUninstall finds m by hashing the archive file,
+// so this path is only taken while that file is still readable. File removal is
+// dispatched across the same bounded worker pool (see --parallel,
+// initVerifyPool) that the archive-walking path uses; the caller is
+// responsible for calling verifyWG.Wait() and checking verifyErr afterwards.
+func removeUsingManifest(m *Manifest) error {
+	targetRoot := filepath.Clean((*targetDir).Name())
+
+	if *verify {
+		if err := verifyManifestDirectories(m, targetRoot); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range m.Files {
+		entry := f
+		verifyFunc := func(file *os.File) (bool, error) {
+			if *verify {
+				sha, err := hashReader(file)
+				if err != nil {
+					return false, err
+				}
+				return sha == entry.Sha256, nil
+			}
+			return true, nil
+		}
+
+		verifyPool <- struct{}{}
+		verifyWG.Add(1)
+		go func() {
+			defer verifyWG.Done()
+			defer func() { <-verifyPool }()
+			recordVerifyErr(removeFromTargetDir(entry.Path, *targetDir, verifyFunc))
+		}()
+	}
+
+	for _, d := range m.Directories {
+		_, escaped, err := resolvePath(targetRoot, d.Path)
+		if err != nil {
+			return err
+		}
+		if escaped {
+			if *strictPaths {
+				return fmt.Errorf(errPathEscapeFmt, d.Path)
+			}
+			continue
+		}
+		addDirectory(d.Path)
+	}
+
+	return nil
+}
+
+func runManifestList(dir string) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		fmt.Println(strings.TrimSuffix(e.Name(), ".json"))
+	}
+	return nil
+}
+
+func runManifestShow(dir, archiveSha256 string) error {
+	m, err := loadManifest(dir, archiveSha256)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(data))
+	return nil
+}
+
+// runManifestGC removes manifests whose recorded files are entirely gone
+// from disk, on the assumption that the install they described was already
+// uninstalled or removed by hand.
+func runManifestGC(dir string) error {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+
+		archiveSha256 := strings.TrimSuffix(e.Name(), ".json")
+		m, err := loadManifest(dir, archiveSha256)
+		if err != nil {
+			continue
+		}
+
+		stale := true
+		for _, f := range m.Files {
+			if _, err := os.Lstat(filepath.Join(m.TargetDir, f.Path)); err == nil {
+				stale = false
+				break
+			}
+		}
+
+		if stale {
+			os.Remove(manifestPath(dir, archiveSha256))
+		}
+	}
+	return nil
+}
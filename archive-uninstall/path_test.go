@@ -0,0 +1,224 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestResolvePathRejectsTraversal(t *testing.T) {
+	root := t.TempDir()
+
+	cases := []struct {
+		name    string
+		member  string
+		escaped bool
+	}{
+		{"plain file", "file.txt", false},
+		{"nested file", "sub/dir/file.txt", false},
+		{"parent traversal", "../escape.txt", true},
+		{"deep parent traversal", "../../../etc/passwd", true},
+		{"traversal that lands back inside root", "sub/../file.txt", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, escaped, err := resolvePath(root, c.member)
+			if err != nil {
+				t.Fatalf("resolvePath(%q) returned error: %v", c.member, err)
+			}
+			if escaped != c.escaped {
+				t.Errorf("resolvePath(%q) escaped = %v, want %v", c.member, escaped, c.escaped)
+			}
+		})
+	}
+}
+
+func TestResolvePathRejectsSymlinkEscape(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// direct-link points straight outside root.
+	if err := os.Symlink(outside, filepath.Join(root, "direct-link")); err != nil {
+		t.Fatal(err)
+	}
+
+	// chain-a -> chain-b -> outside: the escape is only visible after
+	// following the whole symlink chain, not just the first hop.
+	if err := os.Symlink(filepath.Join(root, "chain-b"), filepath.Join(root, "chain-a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(root, "chain-b")); err != nil {
+		t.Fatal(err)
+	}
+
+	// legit points outside root too, but unlike secret.txt above, the file
+	// a member path would write through it doesn't exist yet. This is the
+	// second half of a tar-slip: a symlink entry followed by a regular file
+	// entry whose path runs through it. filepath.EvalSymlinks(joined) fails
+	// with NotExist here since the leaf is missing, which must not be
+	// mistaken for "nothing to resolve".
+	if err := os.Symlink(outside, filepath.Join(root, "legit")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, member := range []string{
+		filepath.Join("direct-link", "secret.txt"),
+		filepath.Join("chain-a", "secret.txt"),
+		filepath.Join("legit", "newfile.txt"),
+	} {
+		_, escaped, err := resolvePath(root, member)
+		if err != nil {
+			t.Fatalf("resolvePath(%q) returned error: %v", member, err)
+		}
+		if !escaped {
+			t.Errorf("resolvePath(%q) escaped = false, want true", member)
+		}
+	}
+}
+
+// buildTarFixture crafts an in-memory tar archive with the given entry names,
+// mirroring what a Zip-Slip-style malicious archive looks like on disk.
+func buildTarFixture(t *testing.T, names []string) []string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for _, name := range names {
+		if err := w.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: 0}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r := tar.NewReader(bytes.NewReader(buf.Bytes()))
+	var parsed []string
+	for {
+		hdr, err := r.Next()
+		if err != nil {
+			break
+		}
+		parsed = append(parsed, hdr.Name)
+	}
+	return parsed
+}
+
+// buildZipFixture crafts an in-memory zip archive the same way.
+func buildZipFixture(t *testing.T, names []string) []string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for _, name := range names {
+		if _, err := w.Create(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed []string
+	for _, f := range zr.File {
+		parsed = append(parsed, f.Name)
+	}
+	return parsed
+}
+
+func withTargetDir(t *testing.T, dir string) {
+	t.Helper()
+	f, err := os.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+
+	savedTarget := *targetDir
+	*targetDir = f
+	t.Cleanup(func() { *targetDir = savedTarget })
+}
+
+func TestRemoveFromTargetDirHonorsStrictPaths(t *testing.T) {
+	root := t.TempDir()
+	withTargetDir(t, root)
+
+	names := buildTarFixture(t, []string{"../../../tmp/evil", "ok.txt"})
+	escapee, safe := names[0], names[1]
+
+	noopVerify := func(*os.File) (bool, error) { return true, nil }
+
+	savedStrict := *strictPaths
+	t.Cleanup(func() { *strictPaths = savedStrict })
+
+	*strictPaths = false
+	if err := removeFromTargetDir(escapee, *targetDir, noopVerify); err != nil {
+		t.Errorf("non-strict escape should be silently skipped, got error: %v", err)
+	}
+
+	*strictPaths = true
+	if err := removeFromTargetDir(escapee, *targetDir, noopVerify); err == nil {
+		t.Error("strict mode should reject a path escape, got nil error")
+	}
+
+	if err := removeFromTargetDir(safe, *targetDir, noopVerify); err != nil {
+		t.Errorf("removeFromTargetDir(%q) should not error on a path inside root: %v", safe, err)
+	}
+}
+
+func TestRemoveFromTargetDirHonorsStrictPathsZip(t *testing.T) {
+	root := t.TempDir()
+	withTargetDir(t, root)
+
+	names := buildZipFixture(t, []string{"../escape.txt", "nested/ok.txt"})
+	escapee, safe := names[0], names[1]
+
+	noopVerify := func(*os.File) (bool, error) { return true, nil }
+
+	savedStrict := *strictPaths
+	t.Cleanup(func() { *strictPaths = savedStrict })
+
+	*strictPaths = true
+	if err := removeFromTargetDir(escapee, *targetDir, noopVerify); err == nil {
+		t.Error("strict mode should reject a zip entry that escapes root, got nil error")
+	}
+
+	*strictPaths = false
+	if err := removeFromTargetDir(safe, *targetDir, noopVerify); err != nil {
+		t.Errorf("removeFromTargetDir(%q) should not error on a path inside root: %v", safe, err)
+	}
+}
+
+func TestEntryCallbackRejectsDirectoryEscape(t *testing.T) {
+	root := t.TempDir()
+	withTargetDir(t, root)
+
+	savedDirs, savedStrict := directories, *strictPaths
+	t.Cleanup(func() { directories, *strictPaths = savedDirs, savedStrict })
+	directories = nil
+
+	*strictPaths = false
+	if err := entryCallback(Entry{Name: "../../../tmp/victim", IsDir: true}); err != nil {
+		t.Fatalf("non-strict directory escape should be skipped, not errored: %v", err)
+	}
+	for _, d := range directories {
+		if d == "../../../tmp/victim" {
+			t.Fatal("a directory entry that escapes the target root must not be recorded for removal")
+		}
+	}
+
+	*strictPaths = true
+	if err := entryCallback(Entry{Name: "../../../tmp/victim", IsDir: true}); err == nil {
+		t.Fatal("strict mode should reject a directory entry that escapes the target root")
+	}
+}